@@ -0,0 +1,138 @@
+// Optional fallback resolution of imports via `bazel query`, for modules
+// provided by hand-written rules that Gazelle doesn't index.
+package gazelle_haskell_modules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// HaskellBazelBinaryDirective configures the bazel binary used to run the
+// query fallback, e.g.
+//
+//	# gazelle:haskell_bazel_binary bazel
+//
+// The fallback is off by default (an empty path), since shelling out to
+// bazel on every unresolved import would be prohibitively slow for large
+// repos; teams opt in when they have hand-maintained BUILD files Gazelle
+// doesn't otherwise learn about.
+const HaskellBazelBinaryDirective = "haskell_bazel_binary"
+
+// bazelQueryTimeout bounds a single `bazel query` invocation, so that a
+// misconfigured or overloaded query doesn't hang a gazelle run.
+const bazelQueryTimeout = 10 * time.Second
+
+// bazelQueryMaxCalls bounds the number of `bazel query` invocations made
+// during a single gazelle run, so repos with many unresolved imports don't
+// pay for one query invocation per import.
+const bazelQueryMaxCalls = 200
+
+// bazelQueryCacheKey scopes cached results (and the call-count limit) by
+// the bazel binary used, as well as the module name: two Config subtrees
+// can configure different haskell_bazel_binary directives (or have the
+// fallback off entirely), and must not share results with each other.
+type bazelQueryCacheKey struct {
+	bazelBinary string
+	moduleName  string
+}
+
+var (
+	bazelQueryCacheMu   sync.Mutex
+	bazelQueryCache     = map[bazelQueryCacheKey]*label.Label{}
+	bazelQueryCallsMade = map[string]int{}
+)
+
+// findModuleLabelByBazelQuery shells out to `bazelBinary query` to look for
+// a haskell_library or haskell_module rule that exports moduleName. Results
+// (including "not found") are cached in-memory per (bazelBinary, moduleName)
+// for the remainder of the run, mirroring ts_auto_deps's
+// QueryBasedTargetLoader.
+//
+// It returns (nil, nil) if bazelBinary is empty (the fallback is disabled),
+// the query call limit has been reached, or no rule is found.
+func findModuleLabelByBazelQuery(bazelBinary string, moduleName string) (*label.Label, error) {
+	return findModuleLabelByBazelQueryWith(runBazelModuleQuery, bazelBinary, moduleName)
+}
+
+// findModuleLabelByBazelQueryWith is findModuleLabelByBazelQuery with the
+// query function injected, so the caching/limiting logic can be unit
+// tested without shelling out to a real bazel binary.
+func findModuleLabelByBazelQueryWith(
+	query func(bazelBinary, moduleName string) (*label.Label, error),
+	bazelBinary string,
+	moduleName string,
+) (*label.Label, error) {
+	if bazelBinary == "" {
+		return nil, nil
+	}
+
+	bazelQueryCacheMu.Lock()
+	defer bazelQueryCacheMu.Unlock()
+
+	key := bazelQueryCacheKey{bazelBinary: bazelBinary, moduleName: moduleName}
+	if lbl, ok := bazelQueryCache[key]; ok {
+		return lbl, nil
+	}
+	if bazelQueryCallsMade[bazelBinary] >= bazelQueryMaxCalls {
+		return nil, nil
+	}
+	bazelQueryCallsMade[bazelBinary]++
+
+	lbl, err := query(bazelBinary, moduleName)
+	if err != nil {
+		return nil, err
+	}
+	bazelQueryCache[key] = lbl
+	return lbl, nil
+}
+
+// runBazelModuleQuery runs the actual `bazel query`, looking for exactly one
+// haskell_library or haskell_module rule whose name (following Note
+// [haskell_module naming scheme]) matches moduleName.
+func runBazelModuleQuery(bazelBinary string, moduleName string) (*label.Label, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bazelQueryTimeout)
+	defer cancel()
+
+	queryExpr := fmt.Sprintf(
+		`kind("haskell_library|haskell_module", attr(name, "(^|\.)%s$", //...))`,
+		moduleName,
+	)
+	cmd := exec.CommandContext(ctx, bazelBinary, "query", queryExpr, "--output=label")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("bazel query for module %s failed: %v: %s", moduleName, err, stderr.String())
+	}
+
+	lines := nonEmptyLines(stdout.String())
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if len(lines) > 1 {
+		return nil, fmt.Errorf("bazel query for module %s is ambiguous: %v", moduleName, lines)
+	}
+
+	lbl, err := label.Parse(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("bazel query for module %s returned an invalid label %q: %v", moduleName, lines[0], err)
+	}
+	return &lbl, nil
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range bytes.Split([]byte(s), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, string(bytes.TrimSpace(line)))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,93 @@
+package gazelle_haskell_modules
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+func countingQuery(calls *int) func(string, string) (*label.Label, error) {
+	return func(bazelBinary, moduleName string) (*label.Label, error) {
+		*calls++
+		lbl := label.New("", "pkg", moduleName)
+		return &lbl, nil
+	}
+}
+
+func TestFindModuleLabelByBazelQueryDisabled(t *testing.T) {
+	var calls int
+	lbl, err := findModuleLabelByBazelQueryWith(countingQuery(&calls), "", "Data.Foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lbl != nil {
+		t.Errorf("got %v, want nil (fallback disabled)", lbl)
+	}
+	if calls != 0 {
+		t.Errorf("query called %d times, want 0", calls)
+	}
+}
+
+func TestFindModuleLabelByBazelQueryCachesPerBinaryAndModule(t *testing.T) {
+	var calls int
+	query := countingQuery(&calls)
+
+	// Repeated lookups of the same (binary, module) hit the cache.
+	for i := 0; i < 3; i++ {
+		if _, err := findModuleLabelByBazelQueryWith(query, "bazel-a", "Data.Cached"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("query called %d times for repeated lookups, want 1", calls)
+	}
+
+	// A different bazel binary must not reuse bazel-a's cached result.
+	lbl, err := findModuleLabelByBazelQueryWith(query, "bazel-b", "Data.Cached")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("query called %d times after switching binaries, want 2", calls)
+	}
+	if lbl == nil || lbl.Name != "Data.Cached" {
+		t.Errorf("got %v, want a label for Data.Cached", lbl)
+	}
+}
+
+func TestFindModuleLabelByBazelQueryLimitIsPerBinary(t *testing.T) {
+	var calls int
+	query := countingQuery(&calls)
+
+	binary := "bazel-limit-test"
+	for i := 0; i < bazelQueryMaxCalls; i++ {
+		if _, err := findModuleLabelByBazelQueryWith(query, binary, fmt.Sprintf("Data.M%d", i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != bazelQueryMaxCalls {
+		t.Fatalf("query called %d times, want %d", calls, bazelQueryMaxCalls)
+	}
+
+	// One more distinct module on the same binary should be refused: the
+	// per-binary call limit has been reached.
+	lbl, err := findModuleLabelByBazelQueryWith(query, binary, "Data.OneTooMany")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lbl != nil {
+		t.Errorf("got %v, want nil once the call limit is reached", lbl)
+	}
+	if calls != bazelQueryMaxCalls {
+		t.Errorf("query called %d times, want it to stay at %d once the limit is hit", calls, bazelQueryMaxCalls)
+	}
+
+	// A different binary has its own, unexhausted limit.
+	if _, err := findModuleLabelByBazelQueryWith(query, "bazel-limit-test-other", "Data.Fresh"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != bazelQueryMaxCalls+1 {
+		t.Errorf("query called %d times, want %d (a fresh binary's own limit)", calls, bazelQueryMaxCalls+1)
+	}
+}
@@ -0,0 +1,40 @@
+// Cross-language resolution, letting other Gazelle extensions depend on
+// the haskell_module / haskell_library rules we generate.
+package gazelle_haskell_modules
+
+import (
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+// CrossResolve implements resolve.CrossResolver, answering queries from
+// other Gazelle languages that want to depend on a module indexed by
+// gazelle_haskell_modules (e.g. codegen or FFI stub-generating extensions),
+// without duplicating our indexing scheme.
+//
+// Only queries with imp.Lang == "haskell" are answered, using the same import
+// spec formats produced when indexing our own rules:
+//
+//	ImportSpec{Lang: "haskell", Imp: "module_name:Foo.Bar"}
+//	ImportSpec{Lang: "haskell", Imp: "filepath:src/Foo/Bar.hs"}
+//
+// Unlike findModuleLabelByModuleName, there is no "importing rule" here to
+// disambiguate with, so if more than one rule defines the module all of
+// them are returned; it's up to the caller (or a `# gazelle:resolve`
+// override on their end) to pick one.
+func (*haskellModulesLang) CrossResolve(c *config.Config, ix *resolve.RuleIndex, imp resolve.ImportSpec, lang string) []resolve.FindResult {
+	if imp.Lang != haskellResolveLang {
+		return nil
+	}
+
+	res := ix.FindRulesByImport(resolve.ImportSpec{gazelleHaskellModulesName, imp.Imp}, gazelleHaskellModulesName)
+	if len(res) == 0 {
+		return nil
+	}
+
+	results := make([]resolve.FindResult, len(res))
+	for i, r := range res {
+		results[i] = resolve.FindResult{Label: r.Label}
+	}
+	return results
+}
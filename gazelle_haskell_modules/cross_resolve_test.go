@@ -0,0 +1,18 @@
+package gazelle_haskell_modules
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+func TestCrossResolveIgnoresOtherLanguages(t *testing.T) {
+	var lang *haskellModulesLang
+
+	// A nil *resolve.RuleIndex is safe here: CrossResolve must reject a
+	// query for a foreign language before it ever touches ix.
+	got := lang.CrossResolve(nil, nil, resolve.ImportSpec{Lang: "go", Imp: "module_name:Foo.Bar"}, "go")
+	if got != nil {
+		t.Errorf("CrossResolve() for lang %q = %v, want nil", "go", got)
+	}
+}
@@ -19,6 +19,18 @@ import (
 	"strings"
 )
 
+// haskellResolveLang is the language name expected in `# gazelle:resolve`
+// directives that override Haskell module resolution, e.g.
+//
+//   # gazelle:resolve haskell_module haskell Data.Foo //some/pkg:foo
+//   # gazelle:resolve haskell_module haskell filepath:src/Foo.hs //some/pkg:foo
+//
+// Using a dedicated "haskell" language (rather than gazelleHaskellModulesName,
+// which is reserved for our own rule index entries) lets users pin imports
+// that are ambiguous (multiple rules define the same module) or that come
+// from hand-written rules gazelle_haskell_modules does not index.
+const haskellResolveLang = "haskell"
+
 // Note [haskell_module naming scheme]
 //
 // haskell_module rules generated by gazelle_haskell_modules are named
@@ -83,7 +95,7 @@ func setNonHaskellModuleDepsAttribute(
 ) {
 	modules := importData.Modules
 	for _, f := range importData.Srcs {
-		mod, err := findModuleLabelByModuleFilePath(repoRoot, ix, f, r.Name(), from)
+		mod, err := findModuleLabelByModuleFilePath(c, repoRoot, ix, f, r.Name(), from)
 		if err != nil {
 			log.Fatal("On rule ", label.New(from.Repo, from.Pkg, r.Name()), ": ", err)
 		}
@@ -101,11 +113,21 @@ func setNonHaskellModuleDepsAttribute(
 	sort.Strings(moduleStrings)
 
 	deps := make([]string, 0, len(importData.Deps))
+	narrowedDeps := make([]string, 0, len(importData.Deps))
 	for dep, _ := range importData.Deps {
+		// Deps into an external Haskell workspace (e.g. a Stackage snapshot)
+		// are depended on wholesale, not per-module, so they're routed to a
+		// separate attribute instead of being mixed in with same-repo deps
+		// that get replaced by per-module haskell_module deps elsewhere.
+		if isExternalHaskellRepo(c, dep.Repo) {
+			narrowedDeps = append(narrowedDeps, rel(dep, from).String())
+			continue
+		}
 		deps = append(deps, rel(dep, from).String())
 	}
 
 	SetArrayAttr(r, "deps", deps)
+	SetArrayAttr(r, narrowedDepsAttrName(c), narrowedDeps)
 	SetArrayAttr(r, "modules", moduleStrings)
 }
 
@@ -113,17 +135,25 @@ func setNonHaskellModuleDepsAttribute(
 // If the origin of an imported module can't be determined, it
 // is ignored.
 func setHaskellModuleDepsAttribute(
+	c *Config,
 	ix *resolve.RuleIndex,
 	r *rule.Rule,
 	importData *HModuleImportData,
 	from label.Label,
 ) {
 	originalComponentName := importData.OriginatingRule.Name()
+	ownLabel := label.New(from.Repo, from.Pkg, r.Name())
 	depsCapacity := len(importData.ImportedModules)
 	deps := make([]string, 0, depsCapacity)
 	for _, mod := range importData.ImportedModules {
-		dep, err := findModuleLabelByModuleName(ix, importData.Deps, mod, originalComponentName, from)
+		dep, err := findModuleLabelByModuleName(c, ix, importData.Deps, mod, originalComponentName, ownLabel, from)
 		if err != nil {
+			if _, ok := err.(*selfImportError); ok {
+				// A module importing itself (directly, or via its own
+				// .hs-boot file) resolves to no dep, same as an import
+				// whose origin can't be determined.
+				continue
+			}
 			log.Fatal("On rule ", r.Name(), ": ", err)
 		}
 		if dep == nil {
@@ -135,6 +165,40 @@ func setHaskellModuleDepsAttribute(
 	SetArrayAttr(r, "deps", deps)
 }
 
+// selfImportError is returned by findModuleLabelByModuleName when the only
+// candidate label for an import resolves back to the importing rule
+// itself (following the pattern of bazel-gazelle's own selfImportError).
+//
+// This happens legitimately for {-# SOURCE #-} imports of a module's own
+// .hs-boot file, and for modules that are part of a mutually recursive
+// cycle: a plain module-name lookup for the SOURCE import would otherwise
+// either point back at the importer or find both the .hs and .hs-boot
+// rules ambiguously.
+type selfImportError struct {
+	lbl label.Label
+}
+
+func (e *selfImportError) Error() string {
+	return fmt.Sprintf("%v imports itself", e.lbl)
+}
+
+// ImportedModule is a single module import as reported by the parser,
+// tagged with whether it is a {-# SOURCE #-} import.
+type ImportedModule struct {
+	Name         string
+	SourceImport bool
+}
+
+// selfImportCheck returns a *selfImportError when res contains only
+// ownLabel, i.e. the only rule found to define the imported module is the
+// rule currently being resolved for.
+func selfImportCheck(res []resolve.FindResult, ownLabel label.Label) error {
+	if len(res) != 1 || res[0].Label != ownLabel {
+		return nil
+	}
+	return &selfImportError{lbl: ownLabel}
+}
+
 // Yields the label of a module with the given name.
 //
 // The label is chosen according to the first of the following
@@ -149,18 +213,51 @@ func setHaskellModuleDepsAttribute(
 //
 // 3. If multiple rules define the module, an error is returned.
 //
-// 4. If no rule defines the module, nil is returned.
+// 4. If neither of the above picks a rule, the module mapping configured
+// via the haskell_module_mapping directive (if any) is consulted, to
+// resolve modules coming from external packages such as Hackage or
+// Stackage.
 //
+// 5. Otherwise, if a haskell_bazel_binary is configured, `bazel query` is
+// used as a last resort to find a hand-written rule exporting the module.
+//
+// Steps 4 and 5 are skipped for {-# SOURCE #-} imports.
+//
+// 6. If none of the above apply, nil is returned.
+//
+// Before any of the above, a `# gazelle:resolve haskell_module haskell ...`
+// override for the module, if present, always takes precedence. This gives
+// users an escape hatch when none of the above criteria pick the rule they
+// intend, or when the module is provided by a hand-written rule that isn't
+// indexed at all.
 func findModuleLabelByModuleName(
+	c *Config,
 	ix *resolve.RuleIndex,
 	mapDep map[label.Label]bool,
-	moduleName string,
+	mod ImportedModule,
 	originalComponentName string,
+	ownLabel label.Label,
 	from label.Label,
 ) (*label.Label, error) {
-	spec := resolve.ImportSpec{gazelleHaskellModulesName, "module_name:" + moduleName}
+	moduleName := mod.Name
+
+	if lbl, ok := resolve.FindRuleWithOverride(c.Config, resolve.ImportSpec{"haskell_module", moduleName}, haskellResolveLang); ok {
+		lbl = rel(lbl, from)
+		return &lbl, nil
+	}
+
+	// {-# SOURCE #-} imports must resolve to the .hs-boot rule, which is
+	// indexed separately from the plain module (see moduleNameImportSpec)
+	// so a lookup here can't accidentally return both (or the importer's
+	// own .hs rule, for mutually recursive modules that import each
+	// other's boot file).
+	spec := moduleNameImportSpec(moduleName, mod.SourceImport)
 	res := ix.FindRulesByImport(spec, gazelleHaskellModulesName)
 
+	if err := selfImportCheck(res, ownLabel); err != nil {
+		return nil, err
+	}
+
 	var finalLabel *label.Label
 	for _, r := range res {
 		if _, ok := mapDep[r.Label]; ok {
@@ -198,10 +295,30 @@ func findModuleLabelByModuleName(
 		return finalLabel, nil
 	}
 
-	return nil, nil
+	// The haskell_module_mapping file and SOURCE imports don't mix: the
+	// mapping is for external packages, which have no notion of .hs-boot
+	// files, so a SOURCE import with no boot rule indexed is just not found.
+	if mod.SourceImport {
+		return nil, nil
+	}
+
+	mapping, err := moduleMappingForConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	if mapping != nil {
+		if lbl, ok := mapping.find(moduleName); ok {
+			return &lbl, nil
+		}
+	}
+
+	// Last resort: ask bazel directly about hand-written rules Gazelle
+	// doesn't index. Disabled unless haskell_bazel_binary is configured.
+	return findModuleLabelByBazelQuery(c.HaskellBazelBinary, moduleName)
 }
 
 func findModuleLabelByModuleFilePath(
+	c *Config,
 	repoRoot string,
 	ix *resolve.RuleIndex,
 	moduleFilePath string,
@@ -213,6 +330,11 @@ func findModuleLabelByModuleFilePath(
 		return nil, fmt.Errorf("Can't make src relative: %q: %v", moduleFilePath, err)
 	}
 
+	if lbl, ok := resolve.FindRuleWithOverride(c.Config, resolve.ImportSpec{"haskell_module", "filepath:" + relModuleFilePath}, haskellResolveLang); ok {
+		lbl = rel(lbl, from)
+		return &lbl, nil
+	}
+
 	spec := resolve.ImportSpec{gazelleHaskellModulesName, "filepath:" + relModuleFilePath}
 	res := ix.FindRulesByImport(spec, gazelleHaskellModulesName)
 
@@ -239,6 +361,22 @@ func findModuleLabelByModuleFilePath(
 	}
 }
 
+// moduleNameImportSpec is the ImportSpec a haskell_module rule is indexed
+// under for its module name. isBoot must be true for a rule generated from
+// a .hs-boot file, and false for the plain .hs module; the two are indexed
+// under distinguished specs so that a {-# SOURCE #-} import can resolve to
+// the boot rule specifically, instead of finding both.
+//
+// This is shared between indexing (done when generating haskell_module
+// rules) and lookup (findModuleLabelByModuleName) so the two can't drift
+// apart.
+func moduleNameImportSpec(moduleName string, isBoot bool) resolve.ImportSpec {
+	if isBoot {
+		return resolve.ImportSpec{gazelleHaskellModulesName, "boot_module_name:" + moduleName}
+	}
+	return resolve.ImportSpec{gazelleHaskellModulesName, "module_name:" + moduleName}
+}
+
 // dep must be an absolute Label
 func isIndexedNonHaskellModuleRule(ix *resolve.RuleIndex, dep label.Label) bool {
 	spec := resolve.ImportSpec{gazelleHaskellModulesName, "label:" + dep.String()}
@@ -0,0 +1,47 @@
+package gazelle_haskell_modules
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+func TestSelfImportCheck(t *testing.T) {
+	own := label.New("", "pkg", "lib.Foo.Bar")
+	other := label.New("", "pkg", "lib.Foo.Baz")
+
+	tests := []struct {
+		name    string
+		res     []resolve.FindResult
+		wantErr bool
+	}{
+		{"no results", nil, false},
+		{"single other result", []resolve.FindResult{{Label: other}}, false},
+		{"multiple results including self", []resolve.FindResult{{Label: own}, {Label: other}}, false},
+		{"single self result", []resolve.FindResult{{Label: own}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := selfImportCheck(tt.res, own)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("selfImportCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModuleNameImportSpec(t *testing.T) {
+	got := moduleNameImportSpec("Foo.Bar", false)
+	want := resolve.ImportSpec{gazelleHaskellModulesName, "module_name:Foo.Bar"}
+	if got != want {
+		t.Errorf("moduleNameImportSpec(isBoot=false) = %#v, want %#v", got, want)
+	}
+
+	got = moduleNameImportSpec("Foo.Bar", true)
+	want = resolve.ImportSpec{gazelleHaskellModulesName, "boot_module_name:Foo.Bar"}
+	if got != want {
+		t.Errorf("moduleNameImportSpec(isBoot=true) = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Directive parsing: turns `# gazelle:...` directives into per-directory
+// Config values, following the standard Gazelle config-inheritance pattern
+// (each directory's Config is cloned from its parent, then amended with
+// whatever directives appear in that directory's BUILD/package file).
+package gazelle_haskell_modules
+
+import (
+	"path/filepath"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// KnownDirectives lists the directives gazelle_haskell_modules understands,
+// so gazelle's directive parser doesn't reject them.
+func (*haskellModulesLang) KnownDirectives() []string {
+	return []string{
+		HaskellModuleMappingDirective,
+		HaskellBazelBinaryDirective,
+		HaskellExternalRepoDirective,
+		HaskellNarrowedDepsAttrDirective,
+	}
+}
+
+// Configure derives this directory's Config from its parent's (or creates
+// one at the repo root), applies any directives found in f, and stores the
+// result in c.Exts so it's visible to the rest of this language's passes
+// for this directory and its descendants.
+func (*haskellModulesLang) Configure(c *config.Config, rel string, f *rule.File) {
+	hc := cloneHaskellConfig(c)
+
+	if f != nil {
+		for _, d := range f.Directives {
+			switch d.Key {
+			case HaskellModuleMappingDirective:
+				hc.HaskellModuleMappingFile = filepath.Join(c.RepoRoot, rel, d.Value)
+			case HaskellBazelBinaryDirective:
+				hc.HaskellBazelBinary = d.Value
+			case HaskellExternalRepoDirective:
+				// Copy-on-write: hc.HaskellExternalRepos may still be
+				// aliasing the parent directory's map at this point.
+				repos := make(map[string]bool, len(hc.HaskellExternalRepos)+1)
+				for repo := range hc.HaskellExternalRepos {
+					repos[repo] = true
+				}
+				repos[d.Value] = true
+				hc.HaskellExternalRepos = repos
+			case HaskellNarrowedDepsAttrDirective:
+				hc.HaskellNarrowedDepsAttr = d.Value
+			}
+		}
+	}
+
+	c.Exts[gazelleHaskellModulesName] = hc
+}
+
+// cloneHaskellConfig returns a copy of the current directory's Config
+// (inherited from its parent directory, or a fresh zero Config at the repo
+// root), ready to be amended with this directory's own directives.
+func cloneHaskellConfig(c *config.Config) *Config {
+	if parent, ok := c.Exts[gazelleHaskellModulesName].(*Config); ok {
+		clone := *parent
+		clone.Config = c
+		return &clone
+	}
+	return &Config{Config: c}
+}
+
+// haskellConfig retrieves the Config stored by Configure for this directory.
+func haskellConfig(c *config.Config) *Config {
+	return c.Exts[gazelleHaskellModulesName].(*Config)
+}
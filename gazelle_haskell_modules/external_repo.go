@@ -0,0 +1,45 @@
+// Passthrough handling for dependencies that live in an external Haskell
+// workspace, such as a prebuilt Stackage snapshot exposed as its own Bazel
+// repository.
+package gazelle_haskell_modules
+
+// HaskellExternalRepoDirective registers a repo as an external Haskell
+// workspace, e.g.
+//
+//	# gazelle:haskell_external_repo stackage
+//
+// Deps of haskell_library, haskell_binary and haskell_test rules whose
+// label points into such a repo are depended on wholesale: they're routed
+// into the attribute named by narrowedDepsAttrName (see
+// HaskellNarrowedDepsAttrDirective) instead of the regular deps attribute,
+// and are never treated as same-repo dependencies to be replaced by
+// per-module haskell_module deps, since external workspaces aren't indexed
+// by gazelle_haskell_modules at all. The deps of haskell_module rules are
+// not routed this way: labels resolved for their imports all go into deps.
+const HaskellExternalRepoDirective = "haskell_external_repo"
+
+// HaskellNarrowedDepsAttrDirective overrides the attribute external-repo
+// deps are routed into, e.g.
+//
+//	# gazelle:haskell_narrowed_deps_attr narrowed_deps
+//
+// Defaults to narrowedDepsAttrDefault when unset.
+const HaskellNarrowedDepsAttrDirective = "haskell_narrowed_deps_attr"
+
+const narrowedDepsAttrDefault = "narrowed_deps"
+
+// isExternalHaskellRepo reports whether repo has been registered via the
+// haskell_external_repo directive. An empty repo (the main repo, relative
+// labels) is never external.
+func isExternalHaskellRepo(c *Config, repo string) bool {
+	return repo != "" && c.HaskellExternalRepos[repo]
+}
+
+// narrowedDepsAttrName is the attribute external-repo deps are written to,
+// defaulting to narrowedDepsAttrDefault when the directive isn't set.
+func narrowedDepsAttrName(c *Config) string {
+	if c.HaskellNarrowedDepsAttr == "" {
+		return narrowedDepsAttrDefault
+	}
+	return c.HaskellNarrowedDepsAttr
+}
@@ -0,0 +1,152 @@
+// Resolution of external (non-workspace) Haskell module imports via a
+// user-provided module->package mapping file.
+package gazelle_haskell_modules
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// HaskellModuleMappingDirective is the gazelle directive used to point at a
+// file mapping Haskell module names to the Bazel labels that provide them,
+// e.g.
+//
+//	# gazelle:haskell_module_mapping tools/hackage_modules.json
+//
+// This mirrors rules_python's Gazelle extension, which resolves imports of
+// pip packages through a generated `modules_mapping.json`. Here the mapping
+// is expected to cover modules coming from Hackage/Stackage (or any other
+// external Haskell package repository), since those aren't indexed by
+// gazelle_haskell_modules the way workspace sources are.
+const HaskellModuleMappingDirective = "haskell_module_mapping"
+
+// moduleMapping maps Haskell module names to the label of the target that
+// provides them, e.g. "Data.Text" -> "@stackage//:text".
+//
+// A key ending in ".*" is a prefix match, consulted only when no exact match
+// is found, e.g. "Data.Aeson.*" -> "@hackage-aeson//:aeson" matches
+// "Data.Aeson.Types" as well as "Data.Aeson" itself.
+type moduleMapping struct {
+	exact    map[string]label.Label
+	prefixes map[string]label.Label
+}
+
+var (
+	moduleMappingCacheMu sync.Mutex
+	moduleMappingCache   = map[string]*moduleMapping{}
+)
+
+// moduleMappingForConfig loads (and memoizes, once per path) the module
+// mapping configured via the haskell_module_mapping directive. It returns
+// nil if no mapping file has been configured.
+func moduleMappingForConfig(c *Config) (*moduleMapping, error) {
+	path := c.HaskellModuleMappingFile
+	if path == "" {
+		return nil, nil
+	}
+
+	moduleMappingCacheMu.Lock()
+	defer moduleMappingCacheMu.Unlock()
+
+	if m, ok := moduleMappingCache[path]; ok {
+		return m, nil
+	}
+
+	m, err := loadModuleMapping(path)
+	if err != nil {
+		return nil, err
+	}
+	moduleMappingCache[path] = m
+	return m, nil
+}
+
+// loadModuleMapping reads a module mapping from a JSON or CSV file.
+//
+// The JSON form is a flat object: {"Data.Text": "@stackage//:text", ...}.
+// The CSV form is two columns with no header: module name, label.
+func loadModuleMapping(path string) (*moduleMapping, error) {
+	raw := make(map[string]string)
+
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open haskell_module_mapping file %q: %v", path, err)
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("couldn't parse haskell_module_mapping file %q: %v", path, err)
+		}
+	case ".csv":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open haskell_module_mapping file %q: %v", path, err)
+		}
+		defer f.Close()
+		records, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse haskell_module_mapping file %q: %v", path, err)
+		}
+		for _, rec := range records {
+			if len(rec) != 2 {
+				return nil, fmt.Errorf("haskell_module_mapping file %q: expected 2 columns, got %v", path, rec)
+			}
+			raw[rec[0]] = rec[1]
+		}
+	default:
+		return nil, fmt.Errorf("haskell_module_mapping file %q: unsupported extension %q, want .json or .csv", path, ext)
+	}
+
+	m := &moduleMapping{
+		exact:    make(map[string]label.Label),
+		prefixes: make(map[string]label.Label),
+	}
+	for moduleName, lblStr := range raw {
+		lbl, err := label.Parse(lblStr)
+		if err != nil {
+			return nil, fmt.Errorf("haskell_module_mapping file %q: invalid label %q for module %q: %v", path, lblStr, moduleName, err)
+		}
+		if strings.HasSuffix(moduleName, ".*") {
+			// Keep the trailing dot (e.g. "Data.Aeson." rather than
+			// "Data.Aeson") so a later strings.HasPrefix match in find()
+			// can't cross a module-name-segment boundary, e.g. match
+			// "Data.AesonPretty" for a "Data.Aeson.*" entry.
+			prefix := strings.TrimSuffix(moduleName, "*")
+			m.prefixes[prefix] = lbl
+		} else {
+			m.exact[moduleName] = lbl
+		}
+	}
+	return m, nil
+}
+
+// find looks up the label that provides moduleName, first by exact match,
+// then by the longest matching prefix (see moduleMapping doc comment).
+func (m *moduleMapping) find(moduleName string) (label.Label, bool) {
+	if lbl, ok := m.exact[moduleName]; ok {
+		return lbl, true
+	}
+
+	var bestPrefix string
+	var bestLabel label.Label
+	found := false
+	for prefix, lbl := range m.prefixes {
+		// prefix is stored with its trailing dot (e.g. "Data.Aeson."), so
+		// that it also matches the bare module name itself (e.g.
+		// "Data.Aeson"), not just sub-modules (e.g. "Data.Aeson.Types").
+		matches := moduleName == strings.TrimSuffix(prefix, ".") || strings.HasPrefix(moduleName, prefix)
+		if matches && len(prefix) > len(bestPrefix) {
+			bestPrefix = prefix
+			bestLabel = lbl
+			found = true
+		}
+	}
+	return bestLabel, found
+}
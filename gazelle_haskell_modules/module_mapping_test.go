@@ -0,0 +1,69 @@
+package gazelle_haskell_modules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+func newModuleMapping(t *testing.T, raw map[string]string) *moduleMapping {
+	t.Helper()
+	m := &moduleMapping{
+		exact:    make(map[string]label.Label),
+		prefixes: make(map[string]label.Label),
+	}
+	for moduleName, lblStr := range raw {
+		lbl, err := label.Parse(lblStr)
+		if err != nil {
+			t.Fatalf("label.Parse(%q): %v", lblStr, err)
+		}
+		if strings.HasSuffix(moduleName, ".*") {
+			m.prefixes[strings.TrimSuffix(moduleName, "*")] = lbl
+		} else {
+			m.exact[moduleName] = lbl
+		}
+	}
+	return m
+}
+
+func TestModuleMappingFind(t *testing.T) {
+	m := newModuleMapping(t, map[string]string{
+		"Data.Text":    "@stackage//:text",
+		"Data.Aeson.*": "@hackage-aeson//:aeson",
+		"Data.Map.*":   "@stackage//:containers",
+	})
+
+	tests := []struct {
+		module    string
+		wantLabel string
+		wantFound bool
+	}{
+		{"Data.Text", "@stackage//:text", true},
+		{"Data.Aeson", "@hackage-aeson//:aeson", true},
+		{"Data.Aeson.Types", "@hackage-aeson//:aeson", true},
+		{"Data.AesonPretty", "", false},
+		{"Data.AesonPretty.Foo", "", false},
+		{"Data.Map", "@stackage//:containers", true},
+		{"Data.Unrelated", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.module, func(t *testing.T) {
+			lbl, ok := m.find(tt.module)
+			if ok != tt.wantFound {
+				t.Fatalf("find(%q) found = %v, want %v", tt.module, ok, tt.wantFound)
+			}
+			if !ok {
+				return
+			}
+			wantLbl, err := label.Parse(tt.wantLabel)
+			if err != nil {
+				t.Fatalf("label.Parse(%q): %v", tt.wantLabel, err)
+			}
+			if lbl != wantLbl {
+				t.Errorf("find(%q) = %v, want %v", tt.module, lbl, wantLbl)
+			}
+		})
+	}
+}
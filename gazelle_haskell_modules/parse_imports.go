@@ -0,0 +1,36 @@
+// Parsing of Haskell import declarations.
+package gazelle_haskell_modules
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// importDeclRegexp matches a single Haskell import declaration, capturing
+// an optional `{-# SOURCE #-}` pragma and the imported module name. It
+// deliberately doesn't parse the rest of the declaration (qualified names,
+// import lists, aliases, etc.), since only the module name and the SOURCE
+// pragma affect dependency resolution.
+var importDeclRegexp = regexp.MustCompile(
+	`(?m)^import\s+(?:\{-#\s*SOURCE\s*#-\}\s+)?(?:qualified\s+)?([A-Z][\w.]*)`,
+)
+
+// sourcePragma is how {-# SOURCE #-} shows up in an importDeclRegexp match,
+// used to tag the resulting ImportedModule.
+var sourcePragma = []byte("SOURCE")
+
+// ParseImportedModules extracts the modules a Haskell source file imports,
+// tagging {-# SOURCE #-} imports (see ImportedModule) so
+// findModuleLabelByModuleName can resolve them against the corresponding
+// .hs-boot rule instead of the plain module.
+func ParseImportedModules(src []byte) []ImportedModule {
+	matches := importDeclRegexp.FindAllSubmatch(src, -1)
+	modules := make([]ImportedModule, 0, len(matches))
+	for _, m := range matches {
+		modules = append(modules, ImportedModule{
+			Name:         string(m[1]),
+			SourceImport: bytes.Contains(m[0], sourcePragma),
+		})
+	}
+	return modules
+}
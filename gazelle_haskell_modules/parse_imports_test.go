@@ -0,0 +1,28 @@
+package gazelle_haskell_modules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImportedModules(t *testing.T) {
+	src := []byte(`module Foo where
+
+import Data.Text (Text)
+import qualified Data.Map as Map
+import {-# SOURCE #-} Foo.Bar
+import {-# SOURCE #-} qualified Foo.Baz as Baz
+`)
+
+	got := ParseImportedModules(src)
+	want := []ImportedModule{
+		{Name: "Data.Text", SourceImport: false},
+		{Name: "Data.Map", SourceImport: false},
+		{Name: "Foo.Bar", SourceImport: true},
+		{Name: "Foo.Baz", SourceImport: true},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseImportedModules() = %#v, want %#v", got, want)
+	}
+}